@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/arthurgustin/godepth/godepth"
+)
+
+// loadChangedLines runs `git diff --unified=0 revspec` and parses it into
+// the per-file changed line ranges godepth.Options.Changed expects, along
+// with the repository root those paths are relative to. An empty revspec
+// disables -diff filtering entirely (nil, "", nil).
+func loadChangedLines(revspec string) (godepth.ChangedFiles, string, error) {
+	if revspec == "" {
+		return nil, "", nil
+	}
+	root, err := repoRoot()
+	if err != nil {
+		return nil, "", err
+	}
+	out, err := exec.Command("git", "diff", "--unified=0", revspec).Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("git diff %s: %w", revspec, err)
+	}
+	changed, err := godepth.ParseUnifiedDiff(string(out))
+	if err != nil {
+		return nil, "", err
+	}
+	return changed, root, nil
+}
+
+// repoRoot returns the absolute path of the current git repository's
+// working tree, which `git diff` paths are always relative to.
+func repoRoot() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse --show-toplevel: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
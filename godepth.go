@@ -6,26 +6,40 @@
 //      godepth [<flag> ...] <Go file or directory> ...
 //
 // Flags:
-//      -over N   show functions with depth > N only and
-//                return exit code 1 if the output is non-empty
-//      -top N    show the top N most complex functions only
-//      -avg      show the average depth
+//      -over N     show functions with depth > N only and
+//                  return exit code 1 if the output is non-empty
+//      -top N      show the top N most complex functions only
+//      -avg        show the average depth
+//      -format F   output format: text, json or sarif (default "text")
+//      -ignore RE  exclude files whose path matches this regexp
+//      -count K    depth metric: block, nesting or npath (default "nesting")
+//      -pprof F    also write a pprof profile.proto profile of all
+//                  collected depths to F, viewable with `go tool pprof`
+//      -diff REV   only report functions changed by `git diff --unified=0 REV`,
+//                  e.g. -diff origin/main...HEAD
+//
+// When scanning directories, godepth honors Go build constraints: it skips
+// _test.go files, generated files, and files excluded by the current
+// GOOS/GOARCH or build tags, the same way `go build` would.
+//
+// The analysis itself lives in github.com/arthurgustin/godepth/godepth,
+// an importable package for tools that want to embed the same check.
 //
 // The output fields for each line are:
 // <depth> <package> <function> <file:row:column>
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
-	"strings"
+
+	"github.com/arthurgustin/godepth/godepth"
 )
 
 const usageDoc = `Calculate maximum depth of Go functions.
@@ -38,6 +52,13 @@ Flags:
         -top N         show the top N most complex functions only
         -avg           show the average depth over all functions,
                        not depending on whether -over or -top are set
+        -format F      output format: text, json or sarif (default "text")
+        -ignore RE     exclude files whose path matches this regexp
+        -count K       depth metric: block, nesting or npath (default "nesting")
+        -pprof F       also write a pprof profile.proto profile of all
+                       collected depths to F, viewable with 'go tool pprof'
+        -diff REV      only report functions changed by 'git diff --unified=0 REV',
+                       e.g. -diff origin/main...HEAD
 
 The output fields for each line are:
 <depth> <package> <function> <file:row:column>
@@ -49,11 +70,33 @@ func usage() {
 }
 
 var (
-	over     = flag.Int("over", 0, "show functions with depth > N only")
-	top      = flag.Int("top", -1, "show the top N deepest functions only")
-	avg      = flag.Bool("avg", false, "show the average deepness")
+	over   = flag.Int("over", 0, "show functions with depth > N only")
+	top    = flag.Int("top", -1, "show the top N deepest functions only")
+	avg    = flag.Bool("avg", false, "show the average deepness")
+	format = flag.String("format", formatText, "output format: text, json or sarif")
+	ignore = flag.String("ignore", "", "exclude files whose path matches this regexp")
+	count  = flag.String("count", string(godepth.CountNesting), "depth metric: block, nesting or npath")
+	pprof  = flag.String("pprof", "", "also write a pprof profile.proto profile of all collected depths to this file")
+	diff   = flag.String("diff", "", "only report functions changed by `git diff --unified=0 <revspec>`")
 )
 
+// Supported values for -format.
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatSARIF = "sarif"
+)
+
+func validateCount() {
+	switch godepth.CountKind(*count) {
+	case godepth.CountBlock, godepth.CountNesting, godepth.CountNPath:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -count value %q: must be one of %s, %s, %s\n",
+			*count, godepth.CountBlock, godepth.CountNesting, godepth.CountNPath)
+		os.Exit(2)
+	}
+}
+
 func main() {
 	flag.Usage = usage
 	flag.Parse()
@@ -61,9 +104,30 @@ func main() {
 	if len(args) == 0 {
 		usage()
 	}
+	validateCount()
+
+	changed, repoRoot, err := loadChangedLines(*diff)
+	if err != nil {
+		exitError(err)
+	}
+	opts := godepth.Options{
+		Ignore:   compileIgnore(),
+		Count:    godepth.CountKind(*count),
+		Changed:  changed,
+		RepoRoot: repoRoot,
+	}
+	stats, err := godepth.Analyze(args, opts)
+	if err != nil {
+		exitError(err)
+	}
+	sort.Sort(stats)
+
+	if *pprof != "" {
+		if err := writePprofProfile(*pprof, stats); err != nil {
+			exitError(err)
+		}
+	}
 
-	stats := analyze(args)
-	sort.Sort(byDepth(stats))
 	written := writeStats(os.Stdout, stats)
 
 	if *avg {
@@ -75,44 +139,16 @@ func main() {
 	}
 }
 
-func analyze(paths []string) []stat {
-	stats := []stat{}
-	for _, path := range paths {
-		if isDir(path) {
-			stats = analyzeDir(path, stats)
-		} else {
-			stats = analyzeFile(path, stats)
-		}
+// compileIgnore compiles the -ignore flag, or returns nil if it's unset.
+func compileIgnore() *regexp.Regexp {
+	if *ignore == "" {
+		return nil
 	}
-	return stats
-}
-
-func isDir(filename string) bool {
-	fi, err := os.Stat(filename)
-	return err == nil && fi.IsDir()
-}
-
-func analyzeFile(fname string, stats []stat) []stat {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, fname, nil, 0)
+	re, err := regexp.Compile(*ignore)
 	if err != nil {
 		exitError(err)
 	}
-	return buildStats(f, fset, stats)
-}
-
-func analyzeDir(dirname string, stats []stat) []stat {
-	filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".go") {
-			stats = analyzeFile(path, stats)
-		}
-		return err
-	})
-	files, _ := filepath.Glob(filepath.Join(dirname, "*.go"))
-	for _, file := range files {
-		stats = analyzeFile(file, stats)
-	}
-	return stats
+	return re
 }
 
 func exitError(err error) {
@@ -120,132 +156,197 @@ func exitError(err error) {
 	os.Exit(1)
 }
 
-func writeStats(w io.Writer, sortedStats []stat) int {
+func writeStats(w io.Writer, sortedStats godepth.Stats) int {
+	switch *format {
+	case formatJSON:
+		return writeJSONStats(w, sortedStats)
+	case formatSARIF:
+		return writeSARIFStats(w, sortedStats)
+	default:
+		return writeTextStats(w, sortedStats)
+	}
+}
+
+// selectStats returns the prefix of sortedStats that should be reported,
+// honoring -top and -over the same way regardless of -format.
+func selectStats(sortedStats godepth.Stats) godepth.Stats {
 	for i, stat := range sortedStats {
 		if i == *top {
-			return i
+			return sortedStats[:i]
 		}
 		if stat.Depth <= *over {
-			return i
+			return sortedStats[:i]
 		}
+	}
+	return sortedStats
+}
 
+func writeTextStats(w io.Writer, sortedStats godepth.Stats) int {
+	selected := selectStats(sortedStats)
+	for _, stat := range selected {
 		fmt.Fprintln(w, stat)
 	}
-	return len(sortedStats)
+	return len(selected)
 }
 
-func showAverage(stats []stat) {
-	fmt.Printf("Average: %.3g\n", average(stats))
+// funcReport is the JSON representation of a single function's depth.
+type funcReport struct {
+	Depth    int    `json:"depth"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
 }
 
-func average(stats []stat) float64 {
-	total := 0
-	for _, s := range stats {
-		total += s.Depth
-	}
-	return float64(total) / float64(len(stats))
+// summaryReport is the aggregate JSON record emitted after the per-function ones.
+type summaryReport struct {
+	Summary    bool    `json:"summary"`
+	Reported   int     `json:"reported"`
+	Analyzed   int     `json:"analyzed"`
+	Average    float64 `json:"average"`
+	Violations int     `json:"violations"`
 }
 
-type stat struct {
-	PkgName  string
-	FuncName string
-	Depth    int
-	Pos      token.Position
+// writeJSONStats streams one JSON object per function, followed by an
+// aggregate summary object, so CI tools can consume godepth the same way
+// they'd parse line-delimited output from other linters.
+func writeJSONStats(w io.Writer, sortedStats godepth.Stats) int {
+	selected := selectStats(sortedStats)
+	enc := json.NewEncoder(w)
+	for _, s := range selected {
+		enc.Encode(funcReport{
+			Depth:    s.Depth,
+			Package:  s.PkgName,
+			Function: s.FuncName,
+			File:     s.Pos.Filename,
+			Line:     s.Pos.Line,
+			Column:   s.Pos.Column,
+		})
+	}
+	violations := 0
+	for _, s := range sortedStats {
+		if s.Depth > *over {
+			violations++
+		}
+	}
+	enc.Encode(summaryReport{
+		Summary:    true,
+		Reported:   len(selected),
+		Analyzed:   len(sortedStats),
+		Average:    sortedStats.Average(),
+		Violations: violations,
+	})
+	return len(selected)
 }
 
-func (s stat) String() string {
-	return fmt.Sprintf("%d %s %s %s", s.Depth, s.PkgName, s.FuncName, s.Pos)
+// SARIF 2.1.0 types, kept minimal to the subset godepth emits.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
 }
 
-type byDepth []stat
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
 
-func (s byDepth) Len() int      { return len(s) }
-func (s byDepth) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
-func (s byDepth) Less(i, j int) bool {
-	return s[i].Depth >= s[j].Depth
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
 }
 
-func buildStats(f *ast.File, fset *token.FileSet, stats []stat) []stat {
-	for _, decl := range f.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok {
-			stats = append(stats, stat{
-				PkgName:  f.Name.Name,
-				FuncName: funcName(fn),
-				Depth:    depth(fn),
-				Pos:      fset.Position(fn.Pos()),
-			})
-		}
-	}
-	return stats
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
 }
 
-// funcName returns the name representation of a function or method:
-// "(Type).Name" for methods or simply "Name" for functions.
-func funcName(fn *ast.FuncDecl) string {
-	if fn.Recv != nil {
-		typ := fn.Recv.List[0].Type
-		return fmt.Sprintf("(%s).%s", recvString(typ), fn.Name)
-	}
-	return fn.Name.Name
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
 }
 
-// recvString returns a string representation of recv of the
-// form "T", "*T", or "BADRECV" (if not a proper receiver type).
-func recvString(recv ast.Expr) string {
-	switch t := recv.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.StarExpr:
-		return "*" + recvString(t.X)
-	}
-	return "BADRECV"
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
 }
 
-func max(s []int) (m int) {
-	for _, value := range s {
-		if value > m {
-			m = value
-		}
-	}
-	return
+type sarifMessage struct {
+	Text string `json:"text"`
 }
 
-// depth calculates the depth of a function
-func depth(fn *ast.FuncDecl) int {
-	allDepth := []int{}
-	for _, lvl := range fn.Body.List {
-		v := maxDepthVisitor{}
-		ast.Walk(&v, lvl)
-		allDepth = append(allDepth, max(v.NodeDepth))
-	}
-	return max(allDepth)
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
 }
 
-type maxDepthVisitor struct {
-	Depth     int
-	NodeDepth []int
-	Lbrace    token.Pos
-	Rbrace    token.Pos
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
 }
 
-// Visit implements the ast.Visitor interface.
-func (v *maxDepthVisitor) Visit(node ast.Node) ast.Visitor {
-	switch n := node.(type) {
-	case *ast.BlockStmt:
-		if v.Rbrace == 0 && v.Lbrace == 0 {
-			v.Lbrace = n.Lbrace
-			v.Rbrace = n.Rbrace
-		}
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
 
-		if n.Lbrace > v.Lbrace && n.Rbrace > v.Rbrace {
-			v.Depth--
-		}
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
 
-		v.Lbrace = n.Lbrace
-		v.Rbrace = n.Rbrace
-		v.Depth++
-		v.NodeDepth = append(v.NodeDepth, v.Depth)
+const sarifRuleID = "godepth/max-depth"
+
+// writeSARIFStats emits each -over violation as a SARIF result (cut to the
+// first N when -top N is set, as selectStats does for every format), so godepth
+// output can be consumed by the same code-scanning dashboards that already
+// understand gocyclo/gometalinter SARIF output.
+func writeSARIFStats(w io.Writer, sortedStats godepth.Stats) int {
+	selected := selectStats(sortedStats)
+	doc := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "godepth",
+					InformationURI: "https://github.com/arthurgustin/godepth",
+					Rules: []sarifRule{{
+						ID:               sarifRuleID,
+						ShortDescription: sarifMessage{Text: "Function nesting depth exceeds the configured maximum"},
+					}},
+				},
+			},
+			Results: make([]sarifResult, 0, len(selected)),
+		}},
 	}
+	for _, s := range selected {
+		doc.Runs[0].Results = append(doc.Runs[0].Results, sarifResult{
+			RuleID: sarifRuleID,
+			Level:  "warning",
+			Message: sarifMessage{
+				Text: fmt.Sprintf("function %s has depth %d, exceeding the maximum of %d", s.FuncName, s.Depth, *over),
+			},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(s.Pos.Filename)},
+					Region: sarifRegion{
+						StartLine:   s.Pos.Line,
+						StartColumn: s.Pos.Column,
+					},
+				},
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+	return len(selected)
+}
 
-	return v
+func showAverage(stats godepth.Stats) {
+	fmt.Printf("Average: %.3g\n", stats.Average())
 }
@@ -0,0 +1,82 @@
+package godepth
+
+import (
+	"bufio"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of changed line numbers in a file.
+type LineRange struct {
+	Start, End int
+}
+
+// ChangedFiles maps a file path (as it appears in a unified diff, typically
+// relative to the repository root) to the line ranges that diff added or
+// modified in it.
+type ChangedFiles map[string][]LineRange
+
+// Intersects reports whether [start, end] overlaps a changed range recorded
+// for path. path must already be repo-root-relative and use the same
+// separators as the diff it was parsed from (see Options.RepoRoot); this
+// is an exact match, not a suffix match, so two files that merely share a
+// basename in different directories are never confused with each other.
+func (c ChangedFiles) Intersects(path string, start, end int) bool {
+	for _, r := range c[filepath.ToSlash(path)] {
+		if start <= r.End && end >= r.Start {
+			return true
+		}
+	}
+	return false
+}
+
+var hunkHeaderRE = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseUnifiedDiff parses the output of `git diff --unified=0 <revspec>`
+// into the set of line ranges each file gained or changed, for use as
+// Options.Changed.
+func ParseUnifiedDiff(diff string) (ChangedFiles, error) {
+	changed := ChangedFiles{}
+	var current string
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			path := strings.TrimPrefix(line, "+++ ")
+			path = strings.TrimPrefix(path, "b/")
+			if path == "/dev/null" {
+				current = ""
+				continue
+			}
+			current = path
+		case strings.HasPrefix(line, "@@ "):
+			if current == "" {
+				continue
+			}
+			m := hunkHeaderRE.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, err
+			}
+			count := 1
+			if m[2] != "" {
+				count, err = strconv.Atoi(m[2])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if count == 0 {
+				// A zero-line hunk is a pure deletion; nothing was added.
+				continue
+			}
+			changed[current] = append(changed[current], LineRange{Start: start, End: start + count - 1})
+		}
+	}
+	return changed, scanner.Err()
+}
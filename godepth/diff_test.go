@@ -0,0 +1,82 @@
+package godepth
+
+import "testing"
+
+func TestParseUnifiedDiff(t *testing.T) {
+	const diff = `diff --git a/pkg/a.go b/pkg/a.go
+index 1111111..2222222 100644
+--- a/pkg/a.go
++++ b/pkg/a.go
+@@ -10,0 +11,3 @@ func Foo() {
++	x := 1
++	y := 2
++	_ = x + y
+@@ -20 +23 @@ func Bar() {
+-	return 1
++	return 2
+diff --git a/pkg/removed.go b/pkg/removed.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/pkg/removed.go
++++ /dev/null
+@@ -1,5 +0,0 @@
+-package pkg
+`
+
+	changed, err := ParseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatalf("ParseUnifiedDiff: %v", err)
+	}
+
+	want := ChangedFiles{
+		"pkg/a.go": {
+			{Start: 11, End: 13},
+			{Start: 23, End: 23},
+		},
+	}
+	if len(changed) != len(want) {
+		t.Fatalf("got %d changed files, want %d: %v", len(changed), len(want), changed)
+	}
+	for file, wantRanges := range want {
+		gotRanges, ok := changed[file]
+		if !ok {
+			t.Fatalf("missing changed ranges for %s", file)
+		}
+		if len(gotRanges) != len(wantRanges) {
+			t.Fatalf("%s: got %v, want %v", file, gotRanges, wantRanges)
+		}
+		for i, r := range wantRanges {
+			if gotRanges[i] != r {
+				t.Errorf("%s: range %d = %v, want %v", file, i, gotRanges[i], r)
+			}
+		}
+	}
+	if _, ok := changed["pkg/removed.go"]; ok {
+		t.Errorf("deleted file should not appear in changed files")
+	}
+}
+
+func TestChangedFilesIntersects(t *testing.T) {
+	changed := ChangedFiles{
+		"pkg/a.go": {{Start: 10, End: 20}},
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		start, end  int
+		wantMatches bool
+	}{
+		{"exact path, overlapping range", "pkg/a.go", 15, 15, true},
+		{"exact path, no overlap", "pkg/a.go", 21, 30, false},
+		{"different directory, same basename, not an exact match", "sub/pkg/a.go", 15, 15, false},
+		{"unrelated file", "pkg/b.go", 15, 15, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changed.Intersects(tt.path, tt.start, tt.end); got != tt.wantMatches {
+				t.Errorf("Intersects(%q, %d, %d) = %v, want %v", tt.path, tt.start, tt.end, got, tt.wantMatches)
+			}
+		})
+	}
+}
@@ -0,0 +1,555 @@
+// Package godepth computes the maximum nesting depth of Go functions.
+//
+// It is the analysis engine behind the godepth command; importing it
+// directly lets tools such as linters, editor plugins, or CI checks embed
+// the same analysis without shelling out to the binary and parsing its
+// stdout, the way github.com/fzipp/gocyclo exposes Analyze separately from
+// its cmd/gocyclo main.
+package godepth
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CountKind selects which depth metric Analyze computes for each function.
+type CountKind string
+
+// Supported CountKind values.
+const (
+	// CountBlock tracks transitions between sibling *ast.BlockStmt nodes
+	// rather than real nesting constructs, so an if/else-if chain or a
+	// switch with many cases all come out at depth 1. Kept for callers
+	// relying on godepth's original numbers.
+	CountBlock CountKind = "block"
+	// CountNesting increments on entry to any real nesting construct
+	// (if, for, range, switch/select cases, closures, standalone blocks)
+	// and is the default.
+	CountNesting CountKind = "nesting"
+	// CountNPath approximates the NPATH complexity metric (Nejmeh, 1988):
+	// the number of acyclic execution paths through a function. It does
+	// not account for boolean-operator complexity within conditions.
+	CountNPath CountKind = "npath"
+)
+
+// Options configures Analyze.
+type Options struct {
+	// Ignore, if non-nil, excludes any file whose path matches it.
+	Ignore *regexp.Regexp
+	// Count selects the depth metric. The zero value behaves like
+	// CountNesting.
+	Count CountKind
+	// Changed, if non-nil, restricts reporting to functions whose byte
+	// range overlaps a changed line interval, letting Analyze act as a
+	// pre-commit/PR-gate check where only regressions matter. Its keys
+	// are repo-root-relative paths, as produced by `git diff`.
+	Changed ChangedFiles
+	// RepoRoot is the absolute path each analyzed file is made relative
+	// to before matching it against Changed. It should be set whenever
+	// Changed is, unless every path passed to Analyze is already
+	// repo-root-relative (e.g. Analyze is invoked from the repo root).
+	RepoRoot string
+}
+
+func (o Options) countKind() CountKind {
+	if o.Count == "" {
+		return CountNesting
+	}
+	return o.Count
+}
+
+// Stat is the computed depth of a single function.
+type Stat struct {
+	PkgName  string
+	FuncName string
+	Depth    int
+	Pos      token.Position
+}
+
+func (s Stat) String() string {
+	return fmt.Sprintf("%d %s %s %s", s.Depth, s.PkgName, s.FuncName, s.Pos)
+}
+
+// Stats is a collection of Stat, sorted deepest-first by sort.Sort.
+type Stats []Stat
+
+func (s Stats) Len() int      { return len(s) }
+func (s Stats) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s Stats) Less(i, j int) bool {
+	return s[i].Depth >= s[j].Depth
+}
+
+// sorted returns a depth-sorted copy of s, leaving s untouched.
+func (s Stats) sorted() Stats {
+	cp := append(Stats(nil), s...)
+	sort.Sort(cp)
+	return cp
+}
+
+// Top returns the n deepest functions. n < 0 or n > len(s) returns all of s.
+func (s Stats) Top(n int) Stats {
+	sorted := s.sorted()
+	if n < 0 || n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n]
+}
+
+// Over returns the functions whose depth is greater than n, deepest first.
+func (s Stats) Over(n int) Stats {
+	sorted := s.sorted()
+	for i, stat := range sorted {
+		if stat.Depth <= n {
+			return sorted[:i]
+		}
+	}
+	return sorted
+}
+
+// Average returns the mean depth across s, or 0 if s is empty.
+func (s Stats) Average() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	total := 0
+	for _, stat := range s {
+		total += stat.Depth
+	}
+	return float64(total) / float64(len(s))
+}
+
+// Analyze computes depth stats for every function found in paths. Each path
+// may be a single Go file or a directory, which is scanned recursively.
+func Analyze(paths []string, opts Options) (Stats, error) {
+	stats := Stats{}
+	var err error
+	for _, path := range paths {
+		if isDir(path) {
+			stats, err = analyzeDir(path, stats, opts)
+		} else if opts.Ignore == nil || !opts.Ignore.MatchString(path) {
+			stats, err = analyzeFile(path, stats, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+func isDir(filename string) bool {
+	fi, err := os.Stat(filename)
+	return err == nil && fi.IsDir()
+}
+
+func analyzeFile(fname string, stats Stats, opts Options) (Stats, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fname, nil, 0)
+	if err != nil {
+		return stats, err
+	}
+	return buildStats(f, fset, stats, opts), nil
+}
+
+// analyzeDir walks dirname once, visiting each regular .go file at most
+// once, and skips any file that isn't a real build input: files excluded
+// by the current GOOS/GOARCH or build tags (go/build.Context.MatchFile),
+// _test.go files, generated files, and anything matching opts.Ignore.
+func analyzeDir(dirname string, stats Stats, opts Options) (Stats, error) {
+	seen := map[string]bool{}
+	err := filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if opts.Ignore != nil && opts.Ignore.MatchString(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !shouldAnalyze(path, opts.Ignore) || seen[path] {
+			return nil
+		}
+		seen[path] = true
+		var err2 error
+		stats, err2 = analyzeFile(path, stats, opts)
+		return err2
+	})
+	return stats, err
+}
+
+// shouldAnalyze reports whether path is a Go source file that would
+// actually be compiled for the current build context: not a _test.go
+// file, not generated, not excluded by build constraints, and not
+// matched by ignoreRE.
+func shouldAnalyze(path string, ignoreRE *regexp.Regexp) bool {
+	if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+		return false
+	}
+	if ignoreRE != nil && ignoreRE.MatchString(path) {
+		return false
+	}
+	match, err := build.Default.MatchFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil || !match {
+		return false
+	}
+	return !isGeneratedFile(path)
+}
+
+// generatedFileRE matches the canonical "Code generated ... DO NOT EDIT."
+// marker documented at https://golang.org/s/generatedcode.
+var generatedFileRE = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGeneratedFile reports whether path carries the generated-code marker
+// in its first few lines.
+func isGeneratedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 5 && scanner.Scan(); i++ {
+		if generatedFileRE.MatchString(scanner.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// relativeToRepoRoot rewrites path relative to repoRoot, so it can be
+// compared against ChangedFiles keys exactly. If repoRoot is empty or the
+// rewrite fails, path is returned slash-normalized and unchanged.
+func relativeToRepoRoot(path, repoRoot string) string {
+	if repoRoot == "" {
+		return filepath.ToSlash(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(repoRoot, abs)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func buildStats(f *ast.File, fset *token.FileSet, stats Stats, opts Options) Stats {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			pos := fset.Position(fn.Pos())
+			if opts.Changed != nil {
+				rel := relativeToRepoRoot(pos.Filename, opts.RepoRoot)
+				if !opts.Changed.Intersects(rel, pos.Line, fset.Position(fn.End()).Line) {
+					continue
+				}
+			}
+			stats = append(stats, Stat{
+				PkgName:  f.Name.Name,
+				FuncName: funcName(fn),
+				Depth:    depth(fn, opts.countKind()),
+				Pos:      pos,
+			})
+		}
+	}
+	return stats
+}
+
+// funcName returns the name representation of a function or method:
+// "(Type).Name" for methods or simply "Name" for functions.
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv != nil {
+		typ := fn.Recv.List[0].Type
+		return fmt.Sprintf("(%s).%s", recvString(typ), fn.Name)
+	}
+	return fn.Name.Name
+}
+
+// recvString returns a string representation of recv of the
+// form "T", "*T", or "BADRECV" (if not a proper receiver type).
+func recvString(recv ast.Expr) string {
+	switch t := recv.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + recvString(t.X)
+	}
+	return "BADRECV"
+}
+
+func max(s []int) (m int) {
+	for _, value := range s {
+		if value > m {
+			m = value
+		}
+	}
+	return
+}
+
+// depth calculates the depth of a function using the given metric.
+func depth(fn *ast.FuncDecl, kind CountKind) int {
+	switch kind {
+	case CountBlock:
+		return blockDepth(fn)
+	case CountNPath:
+		return npath(fn)
+	default:
+		return nestingDepth(fn)
+	}
+}
+
+// blockDepth is the original depth metric; see CountBlock.
+func blockDepth(fn *ast.FuncDecl) int {
+	allDepth := []int{}
+	for _, lvl := range fn.Body.List {
+		v := maxDepthVisitor{}
+		ast.Walk(&v, lvl)
+		allDepth = append(allDepth, max(v.NodeDepth))
+	}
+	return max(allDepth)
+}
+
+type maxDepthVisitor struct {
+	Depth     int
+	NodeDepth []int
+	Lbrace    token.Pos
+	Rbrace    token.Pos
+}
+
+// Visit implements the ast.Visitor interface.
+func (v *maxDepthVisitor) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.BlockStmt:
+		if v.Rbrace == 0 && v.Lbrace == 0 {
+			v.Lbrace = n.Lbrace
+			v.Rbrace = n.Rbrace
+		}
+
+		if n.Lbrace > v.Lbrace && n.Rbrace > v.Rbrace {
+			v.Depth--
+		}
+
+		v.Lbrace = n.Lbrace
+		v.Rbrace = n.Rbrace
+		v.Depth++
+		v.NodeDepth = append(v.NodeDepth, v.Depth)
+	}
+
+	return v
+}
+
+// nestingDepth walks a function body and returns its maximum nesting depth,
+// incrementing on entry to any real nesting construct: IfStmt, ForStmt,
+// RangeStmt, each switch/type-switch case, each select comm-clause, each
+// FuncLit body, and any standalone BlockStmt used purely for scoping. An
+// else-if chain does not add depth beyond its first branch, since it reads
+// at the same indentation level as the if it continues. FuncLits are only
+// looked for in plain statements: one in the header of an if, for, range,
+// switch or select (its init, condition, post, tag, range expression, case
+// expressions or comm statement) is not walked and adds no depth.
+func nestingDepth(fn *ast.FuncDecl) int {
+	if fn.Body == nil {
+		return 0
+	}
+	c := &nestingCounter{}
+	c.block(fn.Body, 0)
+	return c.max
+}
+
+type nestingCounter struct {
+	max int
+}
+
+func (c *nestingCounter) bump(level int) {
+	if level > c.max {
+		c.max = level
+	}
+}
+
+// block walks the statements of a construct's body at the given level,
+// without incrementing for the block itself.
+func (c *nestingCounter) block(b *ast.BlockStmt, level int) {
+	for _, s := range b.List {
+		c.stmt(s, level)
+	}
+}
+
+func (c *nestingCounter) stmt(stmt ast.Stmt, level int) {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		c.bump(level + 1)
+		c.block(s, level+1)
+	case *ast.IfStmt:
+		c.ifStmt(s, level)
+	case *ast.ForStmt:
+		c.bump(level + 1)
+		c.block(s.Body, level+1)
+	case *ast.RangeStmt:
+		c.bump(level + 1)
+		c.block(s.Body, level+1)
+	case *ast.SwitchStmt:
+		c.caseClauses(s.Body.List, level)
+	case *ast.TypeSwitchStmt:
+		c.caseClauses(s.Body.List, level)
+	case *ast.SelectStmt:
+		c.commClauses(s.Body.List, level)
+	case *ast.LabeledStmt:
+		c.stmt(s.Stmt, level)
+	default:
+		c.funcLits(stmt, level)
+	}
+}
+
+// ifStmt increments once per branch of an if/else-if/else chain, treating
+// "else if" as continuing the chain at the same level rather than nesting
+// deeper, while a genuinely nested if (inside the Body) goes one level
+// deeper than its parent.
+func (c *nestingCounter) ifStmt(s *ast.IfStmt, level int) {
+	c.bump(level + 1)
+	c.block(s.Body, level+1)
+	switch e := s.Else.(type) {
+	case *ast.BlockStmt:
+		c.bump(level + 1)
+		c.block(e, level+1)
+	case *ast.IfStmt:
+		c.ifStmt(e, level)
+	}
+}
+
+func (c *nestingCounter) caseClauses(stmts []ast.Stmt, level int) {
+	for _, cc := range stmts {
+		clause, ok := cc.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		c.bump(level + 1)
+		for _, s := range clause.Body {
+			c.stmt(s, level+1)
+		}
+	}
+}
+
+func (c *nestingCounter) commClauses(stmts []ast.Stmt, level int) {
+	for _, cc := range stmts {
+		clause, ok := cc.(*ast.CommClause)
+		if !ok {
+			continue
+		}
+		c.bump(level + 1)
+		for _, s := range clause.Body {
+			c.stmt(s, level+1)
+		}
+	}
+}
+
+// funcLits finds function literals directly inside stmt (e.g. a goroutine
+// or a deferred closure) and walks their bodies one level deeper, without
+// descending into nested FuncLits twice: once found, the literal's own
+// body is walked through the normal stmt/block recursion.
+func (c *nestingCounter) funcLits(stmt ast.Stmt, level int) {
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		lit, ok := n.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		c.bump(level + 1)
+		c.block(lit.Body, level+1)
+		return false
+	})
+}
+
+// npath implements CountNPath.
+func npath(fn *ast.FuncDecl) int {
+	if fn.Body == nil {
+		return 1
+	}
+	return npathStmts(fn.Body.List)
+}
+
+func npathStmts(stmts []ast.Stmt) int {
+	total := 1
+	for _, s := range stmts {
+		total *= npathStmt(s)
+	}
+	return total
+}
+
+func npathStmt(stmt ast.Stmt) int {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		return npathStmts(s.List)
+	case *ast.IfStmt:
+		then := npathStmts(s.Body.List)
+		if s.Else == nil {
+			return then + 1
+		}
+		return then + npathStmt(s.Else)
+	case *ast.ForStmt:
+		return npathStmts(s.Body.List) + 1
+	case *ast.RangeStmt:
+		return npathStmts(s.Body.List) + 1
+	case *ast.SwitchStmt:
+		return npathClauses(s.Body.List)
+	case *ast.TypeSwitchStmt:
+		return npathClauses(s.Body.List)
+	case *ast.SelectStmt:
+		return npathCommClauses(s.Body.List)
+	case *ast.LabeledStmt:
+		return npathStmt(s.Stmt)
+	default:
+		return npathFuncLits(stmt)
+	}
+}
+
+func npathClauses(stmts []ast.Stmt) int {
+	total := 0
+	for _, cc := range stmts {
+		clause, ok := cc.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		total += npathStmts(clause.Body)
+	}
+	if total == 0 {
+		return 1
+	}
+	return total
+}
+
+func npathCommClauses(stmts []ast.Stmt) int {
+	total := 0
+	for _, cc := range stmts {
+		clause, ok := cc.(*ast.CommClause)
+		if !ok {
+			continue
+		}
+		total += npathStmts(clause.Body)
+	}
+	if total == 0 {
+		return 1
+	}
+	return total
+}
+
+func npathFuncLits(stmt ast.Stmt) int {
+	total := 1
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		lit, ok := n.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		total *= npathStmts(lit.Body.List)
+		return false
+	})
+	return total
+}
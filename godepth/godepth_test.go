@@ -0,0 +1,151 @@
+package godepth
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parseFunc(t *testing.T, src string) *ast.FuncDecl {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", "package p\n"+src, 0)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			return fn
+		}
+	}
+	t.Fatal("no function declaration in source")
+	return nil
+}
+
+func TestNestingDepth(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want int
+	}{
+		{
+			name: "if-else-if-else chain stays flat",
+			src: `func f(a int) {
+				if a == 0 {
+				} else if a == 1 {
+				} else if a == 2 {
+				} else {
+				}
+			}`,
+			want: 1,
+		},
+		{
+			name: "if nested inside if goes one level deeper",
+			src: `func f(a, b int) {
+				if a == 0 {
+					if b == 0 {
+					}
+				}
+			}`,
+			want: 2,
+		},
+		{
+			name: "switch with many cases stays flat",
+			src: `func f(a int) {
+				switch a {
+				case 0:
+				case 1:
+				case 2:
+				default:
+				}
+			}`,
+			want: 1,
+		},
+		{
+			name: "select with many comm clauses stays flat",
+			src: `func f(ch chan int) {
+				select {
+				case <-ch:
+				case ch <- 1:
+				default:
+				}
+			}`,
+			want: 1,
+		},
+		{
+			name: "goroutine literal body is one level deeper",
+			src: `func f() {
+				go func() {
+					if true {
+					}
+				}()
+			}`,
+			want: 2,
+		},
+		{
+			name: "deferred closure body is one level deeper",
+			src: `func f() {
+				defer func() {
+					for {
+					}
+				}()
+			}`,
+			want: 2,
+		},
+		{
+			name: "standalone block is its own level",
+			src: `func f() {
+				{
+					if true {
+					}
+				}
+			}`,
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := parseFunc(t, tt.src)
+			if got := nestingDepth(fn); got != tt.want {
+				t.Errorf("nestingDepth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAnalyzeChangedHonorsRepoRoot guards against matching Changed entries
+// by basename alone: two files named a.go in different directories must
+// not be confused with each other once paths are made repo-root-relative.
+func TestAnalyzeChangedHonorsRepoRoot(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, src string) string {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+		return path
+	}
+
+	rootFile := write("a.go", "package root\n\nfunc Untouched() {}\n")
+	subFile := write("sub/a.go", "package sub\n\nfunc Touched() {}\n")
+
+	opts := Options{
+		RepoRoot: root,
+		Changed:  ChangedFiles{"sub/a.go": {{Start: 1, End: 10}}},
+	}
+	stats, err := Analyze([]string{rootFile, subFile}, opts)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if len(stats) != 1 || stats[0].FuncName != "Touched" {
+		t.Fatalf("got %v, want only the Touched function from sub/a.go", stats)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/arthurgustin/godepth/godepth"
+	"github.com/google/pprof/profile"
+)
+
+// buildProfile turns depth stats into a pprof profile with one sample per
+// function: its value is the computed depth, and its (sole) location points
+// at the function's file:line, so `go tool pprof` can drive a flame graph,
+// top view, and source view over the deepest functions in a large repo.
+func buildProfile(stats godepth.Stats) *profile.Profile {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "depth", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "depth", Unit: "count"},
+		Period:     1,
+	}
+	for i, s := range stats {
+		id := uint64(i + 1)
+		fn := &profile.Function{
+			ID:         id,
+			Name:       fmt.Sprintf("%s.%s", s.PkgName, s.FuncName),
+			SystemName: s.FuncName,
+			Filename:   s.Pos.Filename,
+		}
+		loc := &profile.Location{
+			ID: id,
+			Line: []profile.Line{{
+				Function: fn,
+				Line:     int64(s.Pos.Line),
+			}},
+		}
+		prof.Function = append(prof.Function, fn)
+		prof.Location = append(prof.Location, loc)
+		prof.Sample = append(prof.Sample, &profile.Sample{
+			Location: []*profile.Location{loc},
+			Value:    []int64{int64(s.Depth)},
+		})
+	}
+	return prof
+}
+
+// writePprofProfile writes stats as a gzip-encoded profile.proto file at path.
+func writePprofProfile(path string, stats godepth.Stats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return buildProfile(stats).Write(f)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arthurgustin/godepth/godepth"
+	"github.com/google/pprof/profile"
+)
+
+func TestWritePprofProfile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "sample.go")
+	err := os.WriteFile(src, []byte(`package sample
+
+func Flat() {}
+
+func Nested(a int) {
+	if a > 0 {
+		if a > 1 {
+		}
+	}
+}
+`), 0o644)
+	if err != nil {
+		t.Fatalf("write sample source: %v", err)
+	}
+
+	stats, err := godepth.Analyze([]string{src}, godepth.Options{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	out := filepath.Join(dir, "depth.pprof")
+	if err := writePprofProfile(out, stats); err != nil {
+		t.Fatalf("writePprofProfile: %v", err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("open profile: %v", err)
+	}
+	defer f.Close()
+
+	prof, err := profile.Parse(f)
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+
+	if len(prof.Sample) != len(stats) {
+		t.Fatalf("got %d samples, want %d", len(prof.Sample), len(stats))
+	}
+
+	byName := map[string]*profile.Sample{}
+	for _, sample := range prof.Sample {
+		for _, loc := range sample.Location {
+			for _, line := range loc.Line {
+				byName[line.Function.Name] = sample
+			}
+		}
+	}
+
+	for _, s := range stats {
+		name := s.PkgName + "." + s.FuncName
+		sample, ok := byName[name]
+		if !ok {
+			t.Fatalf("no sample for %s", name)
+		}
+		if got := sample.Value[0]; got != int64(s.Depth) {
+			t.Errorf("%s: got depth %d, want %d", name, got, s.Depth)
+		}
+		if got := sample.Location[0].Line[0].Line; got != int64(s.Pos.Line) {
+			t.Errorf("%s: got line %d, want %d", name, got, s.Pos.Line)
+		}
+	}
+}